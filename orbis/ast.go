@@ -0,0 +1,115 @@
+package orbis
+
+import "regexp"
+
+// Expr is a node in the constraint expression AST produced by Parse.
+type Expr interface {
+	exprNode()
+}
+
+// AndExpr is the conjunction `X & Y`; both sides must hold.
+type AndExpr struct {
+	X, Y Expr
+}
+
+// OrExpr is the disjunction `X | Y`; either side must hold.
+type OrExpr struct {
+	X, Y Expr
+}
+
+// ParenExpr is an expression wrapped in `( ... )`, preserved so error
+// messages and re-printing can reflect the source grouping.
+type ParenExpr struct {
+	X Expr
+}
+
+// NotExpr is the negation `not X`, binding tighter than `&`.
+type NotExpr struct {
+	X Expr
+}
+
+// InExpr is the membership predicate `field in [a, b, c]`.
+type InExpr struct {
+	Field string
+	Items []Expr
+}
+
+// BetweenExpr is the range predicate `field between Low and High`,
+// inclusive of both bounds.
+type BetweenExpr struct {
+	Field     string
+	Low, High Expr
+}
+
+// CmpOp identifies the comparison performed by a CmpExpr.
+type CmpOp int
+
+const (
+	OpGT CmpOp = iota
+	OpGTE
+	OpLT
+	OpLTE
+	OpEQ
+	OpNEQ
+	OpLike
+)
+
+func (op CmpOp) String() string {
+	switch op {
+	case OpGT:
+		return ">"
+	case OpGTE:
+		return ">="
+	case OpLT:
+		return "<"
+	case OpLTE:
+		return "<="
+	case OpEQ:
+		return "="
+	case OpNEQ:
+		return "!="
+	case OpLike:
+		return "like"
+	default:
+		return "?"
+	}
+}
+
+// CmpExpr is a single predicate comparing a named field against a literal
+// value, e.g. `age >= 18` or `name like "bob%"`.
+type CmpExpr struct {
+	Op    CmpOp
+	Field string
+	Value Expr
+
+	// re caches the compiled pattern for an OpLike comparison. Compile
+	// populates it once; Eval falls back to compiling on demand so bare
+	// Eval(Parse(src), row) calls still work without it.
+	re *regexp.Regexp
+}
+
+// StringLit is a string literal value, decoded of its escape sequences.
+type StringLit struct {
+	Value string
+}
+
+// IntLit is an integer literal value.
+type IntLit struct {
+	Value int64
+}
+
+// FloatLit is a floating point literal value.
+type FloatLit struct {
+	Value float64
+}
+
+func (*AndExpr) exprNode()     {}
+func (*OrExpr) exprNode()      {}
+func (*ParenExpr) exprNode()   {}
+func (*NotExpr) exprNode()     {}
+func (*InExpr) exprNode()      {}
+func (*BetweenExpr) exprNode() {}
+func (*CmpExpr) exprNode()     {}
+func (*StringLit) exprNode()   {}
+func (*IntLit) exprNode()      {}
+func (*FloatLit) exprNode()    {}
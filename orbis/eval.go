@@ -0,0 +1,401 @@
+package orbis
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Constraint is a compiled constraint expression, ready to be matched
+// against rows without re-parsing its source on every call.
+type Constraint struct {
+	src  string
+	expr Expr
+}
+
+// Compile parses src once and returns a reusable Constraint. Any `like`
+// patterns in src are compiled to a regular expression here, rather than on
+// every Matches call.
+func Compile(src string) (*Constraint, error) {
+	expr, err := Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	if err := compileLikePatterns(expr); err != nil {
+		return nil, err
+	}
+	return &Constraint{src: src, expr: expr}, nil
+}
+
+// compileLikePatterns walks expr, pre-compiling the regular expression for
+// every OpLike comparison so Eval doesn't have to recompile it per row.
+func compileLikePatterns(expr Expr) error {
+	switch e := expr.(type) {
+	case *AndExpr:
+		if err := compileLikePatterns(e.X); err != nil {
+			return err
+		}
+		return compileLikePatterns(e.Y)
+	case *OrExpr:
+		if err := compileLikePatterns(e.X); err != nil {
+			return err
+		}
+		return compileLikePatterns(e.Y)
+	case *ParenExpr:
+		return compileLikePatterns(e.X)
+	case *NotExpr:
+		return compileLikePatterns(e.X)
+	case *CmpExpr:
+		if e.Op != OpLike {
+			return nil
+		}
+		lit, ok := e.Value.(*StringLit)
+		if !ok {
+			return fmt.Errorf("orbis: like pattern for field %q must be a string literal", e.Field)
+		}
+		re, err := compileLikePattern(lit.Value)
+		if err != nil {
+			return fmt.Errorf("orbis: invalid like pattern for field %q: %v", e.Field, err)
+		}
+		e.re = re
+		return nil
+	default:
+		return nil
+	}
+}
+
+// compileLikePattern turns a SQL-style like pattern ('%' matches any run of
+// characters, '_' matches exactly one) into an anchored regular expression.
+func compileLikePattern(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("(?s)^")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteByte('.')
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteByte('$')
+	return regexp.Compile(b.String())
+}
+
+// Matches reports whether row satisfies the compiled constraint.
+func (c *Constraint) Matches(row interface{}) (bool, error) {
+	return Eval(c.expr, row)
+}
+
+// String returns the source the Constraint was compiled from.
+func (c *Constraint) String() string {
+	return c.src
+}
+
+// Eval evaluates expr against row, which must be a map[string]interface{} or
+// a struct (or pointer to one). Struct fields are matched by their `orbis`
+// tag, falling back to the exported field name.
+func Eval(expr Expr, row interface{}) (bool, error) {
+	switch e := expr.(type) {
+	case *AndExpr:
+		x, err := Eval(e.X, row)
+		if err != nil || !x {
+			return false, err
+		}
+		return Eval(e.Y, row)
+	case *OrExpr:
+		x, err := Eval(e.X, row)
+		if err != nil || x {
+			return x, err
+		}
+		return Eval(e.Y, row)
+	case *ParenExpr:
+		return Eval(e.X, row)
+	case *NotExpr:
+		x, err := Eval(e.X, row)
+		if err != nil {
+			return false, err
+		}
+		return !x, nil
+	case *InExpr:
+		return evalIn(e, row)
+	case *BetweenExpr:
+		return evalBetween(e, row)
+	case *CmpExpr:
+		return evalCmp(e, row)
+	default:
+		return false, fmt.Errorf("orbis: cannot evaluate %T", expr)
+	}
+}
+
+// evalIn reports whether row's e.Field equals any of e.Items, short-
+// circuiting on the first match.
+func evalIn(e *InExpr, row interface{}) (bool, error) {
+	for _, item := range e.Items {
+		eq, err := evalCmp(&CmpExpr{Op: OpEQ, Field: e.Field, Value: item}, row)
+		if err != nil {
+			return false, err
+		}
+		if eq {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// evalBetween reports whether row's e.Field falls within [Low, High].
+func evalBetween(e *BetweenExpr, row interface{}) (bool, error) {
+	ge, err := evalCmp(&CmpExpr{Op: OpGTE, Field: e.Field, Value: e.Low}, row)
+	if err != nil || !ge {
+		return false, err
+	}
+	return evalCmp(&CmpExpr{Op: OpLTE, Field: e.Field, Value: e.High}, row)
+}
+
+func evalCmp(e *CmpExpr, row interface{}) (bool, error) {
+	raw, ok := fieldValue(row, e.Field)
+	if !ok {
+		return false, fmt.Errorf("orbis: field %q not found", e.Field)
+	}
+
+	fv := reflect.ValueOf(raw)
+	for fv.Kind() == reflect.Interface {
+		fv = fv.Elem()
+	}
+
+	if e.Op == OpLike {
+		return evalLike(e, fv)
+	}
+
+	var cmp int
+
+	switch fv.Kind() {
+	case reflect.String:
+		lit, ok := e.Value.(*StringLit)
+		if !ok {
+			return false, fmt.Errorf("orbis: field %q is a string, cannot compare to %T", e.Field, e.Value)
+		}
+		cmp = strings.Compare(fv.String(), lit.Value)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := literalToInt64(e.Value, fv.Type().Bits())
+		if err != nil {
+			return false, err
+		}
+		cmp = compareInt64(fv.Int(), n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := literalToUint64(e.Value, fv.Type().Bits())
+		if err != nil {
+			return false, err
+		}
+		cmp = compareUint64(fv.Uint(), n)
+
+	case reflect.Float32, reflect.Float64:
+		n, err := literalToFloat64(e.Value)
+		if err != nil {
+			return false, err
+		}
+		cmp = compareFloat64(fv.Float(), n)
+
+	default:
+		return false, fmt.Errorf("orbis: field %q has unsupported type %s", e.Field, fv.Type())
+	}
+
+	switch e.Op {
+	case OpGT:
+		return cmp > 0, nil
+	case OpGTE:
+		return cmp >= 0, nil
+	case OpLT:
+		return cmp < 0, nil
+	case OpLTE:
+		return cmp <= 0, nil
+	case OpEQ:
+		return cmp == 0, nil
+	case OpNEQ:
+		return cmp != 0, nil
+	default:
+		return false, fmt.Errorf("orbis: unknown comparison operator %v", e.Op)
+	}
+}
+
+// evalLike matches fv against e's like pattern, compiling it on demand if
+// Compile hasn't already done so.
+func evalLike(e *CmpExpr, fv reflect.Value) (bool, error) {
+	if fv.Kind() != reflect.String {
+		return false, fmt.Errorf("orbis: field %q is not a string, cannot use like", e.Field)
+	}
+
+	re := e.re
+	if re == nil {
+		lit, ok := e.Value.(*StringLit)
+		if !ok {
+			return false, fmt.Errorf("orbis: like pattern for field %q must be a string literal", e.Field)
+		}
+		var err error
+		re, err = compileLikePattern(lit.Value)
+		if err != nil {
+			return false, fmt.Errorf("orbis: invalid like pattern for field %q: %v", e.Field, err)
+		}
+	}
+
+	return re.MatchString(fv.String()), nil
+}
+
+// fieldValue looks up name in row, which is either a map[string]interface{}
+// or a struct (or pointer to one) with exported fields, optionally tagged
+// `orbis:"name"`.
+func fieldValue(row interface{}, name string) (interface{}, bool) {
+	if m, ok := row.(map[string]interface{}); ok {
+		v, ok := m[name]
+		return v, ok
+	}
+
+	v := reflect.ValueOf(row)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, false
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, false
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue // unexported
+		}
+		if tag, ok := f.Tag.Lookup("orbis"); ok {
+			if tag == name {
+				return v.Field(i).Interface(), true
+			}
+			continue
+		}
+		if f.Name == name {
+			return v.Field(i).Interface(), true
+		}
+	}
+	return nil, false
+}
+
+func checkIntRange(bits int, v int64) error {
+	if bits <= 0 || bits >= 64 {
+		return nil
+	}
+	limit := int64(1) << uint(bits-1)
+	if v < -limit || v >= limit {
+		return fmt.Errorf("orbis: value %d overflows a %d-bit integer field", v, bits)
+	}
+	return nil
+}
+
+func checkUintRange(bits int, v uint64) error {
+	if bits <= 0 || bits >= 64 {
+		return nil
+	}
+	limit := uint64(1) << uint(bits)
+	if v >= limit {
+		return fmt.Errorf("orbis: value %d overflows a %d-bit unsigned integer field", v, bits)
+	}
+	return nil
+}
+
+func literalToInt64(expr Expr, bits int) (int64, error) {
+	switch lit := expr.(type) {
+	case *IntLit:
+		if err := checkIntRange(bits, lit.Value); err != nil {
+			return 0, err
+		}
+		return lit.Value, nil
+	case *FloatLit:
+		if lit.Value != math.Trunc(lit.Value) {
+			return 0, fmt.Errorf("orbis: %v is not an integer", lit.Value)
+		}
+		n := int64(lit.Value)
+		if err := checkIntRange(bits, n); err != nil {
+			return 0, err
+		}
+		return n, nil
+	default:
+		return 0, fmt.Errorf("orbis: cannot compare an integer field to %T", expr)
+	}
+}
+
+func literalToUint64(expr Expr, bits int) (uint64, error) {
+	switch lit := expr.(type) {
+	case *IntLit:
+		if lit.Value < 0 {
+			return 0, fmt.Errorf("orbis: cannot compare an unsigned integer field to negative value %d", lit.Value)
+		}
+		u := uint64(lit.Value)
+		if err := checkUintRange(bits, u); err != nil {
+			return 0, err
+		}
+		return u, nil
+	case *FloatLit:
+		if lit.Value != math.Trunc(lit.Value) {
+			return 0, fmt.Errorf("orbis: %v is not an integer", lit.Value)
+		}
+		if lit.Value < 0 {
+			return 0, fmt.Errorf("orbis: cannot compare an unsigned integer field to negative value %v", lit.Value)
+		}
+		u := uint64(lit.Value)
+		if err := checkUintRange(bits, u); err != nil {
+			return 0, err
+		}
+		return u, nil
+	default:
+		return 0, fmt.Errorf("orbis: cannot compare an unsigned integer field to %T", expr)
+	}
+}
+
+func literalToFloat64(expr Expr) (float64, error) {
+	switch lit := expr.(type) {
+	case *IntLit:
+		return float64(lit.Value), nil
+	case *FloatLit:
+		return lit.Value, nil
+	default:
+		return 0, fmt.Errorf("orbis: cannot compare a float field to %T", expr)
+	}
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareUint64(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareFloat64(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
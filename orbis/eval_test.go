@@ -0,0 +1,66 @@
+package orbis
+
+import "testing"
+
+func TestConstraintMatches(t *testing.T) {
+	type person struct {
+		Name string `orbis:"name"`
+		Age  int8   `orbis:"age"`
+	}
+
+	tests := []struct {
+		src  string
+		row  interface{}
+		want bool
+	}{
+		{`age >= 18 & name = "bob"`, map[string]interface{}{"age": 21, "name": "bob"}, true},
+		{`age >= 18 & name = "bob"`, map[string]interface{}{"age": 17, "name": "bob"}, false},
+		{`age >= 18 & name = "bob"`, person{Name: "bob", Age: 21}, true},
+		{`age >= 18 & name = "bob"`, &person{Name: "carol", Age: 21}, false},
+		{`age < 10 | name = "bob"`, map[string]interface{}{"age": 40, "name": "bob"}, true},
+		{`name != "bob"`, map[string]interface{}{"age": 1, "name": "carol"}, true},
+		{`age in [17, 18, 19]`, map[string]interface{}{"age": 18, "name": "bob"}, true},
+		{`age in [17, 18, 19]`, map[string]interface{}{"age": 21, "name": "bob"}, false},
+		{`age between 18 and 30`, map[string]interface{}{"age": 21, "name": "bob"}, true},
+		{`age between 18 and 30`, map[string]interface{}{"age": 17, "name": "bob"}, false},
+		{`name like "b_b"`, map[string]interface{}{"age": 1, "name": "bob"}, true},
+		{`name like "c%"`, map[string]interface{}{"age": 1, "name": "bob"}, false},
+		{`not age >= 18`, map[string]interface{}{"age": 17, "name": "bob"}, true},
+		{`not age >= 18`, map[string]interface{}{"age": 18, "name": "bob"}, false},
+	}
+
+	for _, tt := range tests {
+		c, err := Compile(tt.src)
+		if err != nil {
+			t.Fatalf("Compile(%q) returned error: %v", tt.src, err)
+		}
+		got, err := c.Matches(tt.row)
+		if err != nil {
+			t.Fatalf("Matches(%v) for %q returned error: %v", tt.row, tt.src, err)
+		}
+		if got != tt.want {
+			t.Errorf("Matches(%v) for %q = %v, want %v", tt.row, tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestConstraintMatchesErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		row interface{}
+	}{
+		{`missing = 1`, map[string]interface{}{"age": 1}},
+		{`age = 1000`, map[string]interface{}{"age": int8(1)}},
+		{`age = "bob"`, map[string]interface{}{"age": 1}},
+	}
+
+	for _, tt := range tests {
+		c, err := Compile(tt.src)
+		if err != nil {
+			t.Fatalf("Compile(%q) returned error: %v", tt.src, err)
+		}
+		if _, err := c.Matches(tt.row); err == nil {
+			t.Errorf("Matches(%v) for %q succeeded, want error", tt.row, tt.src)
+		}
+	}
+}
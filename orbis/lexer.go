@@ -0,0 +1,727 @@
+// Package orbis implements a small constraint DSL used to filter rows by
+// comparing named fields against literal values, e.g. `age >= 18 & name = "bob"`.
+package orbis
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TokenType identifies the lexical class of a Token.
+type TokenType int
+
+const (
+	TokenGT TokenType = iota
+	TokenGTE
+	TokenLT
+	TokenLTE
+	TokenEQ
+	TokenNEQ
+	TokenAND
+	TokenOR
+	TokenText
+	TokenInt
+	TokenFloat
+	TokenStringStart
+	TokenStringEnd
+	TokenBracketStart
+	TokenBracketEnd
+	TokenIdent
+	TokenImag
+	TokenRawString
+	TokenBlockString
+	TokenListStart
+	TokenListEnd
+	TokenComma
+	TokenIn
+	TokenBetween
+	TokenLike
+	TokenNot
+)
+
+// keywords maps the reserved identifiers of the constraint DSL to their
+// token type, checked once an identifier has been fully scanned.
+var keywords = map[string]TokenType{
+	"in":      TokenIn,
+	"between": TokenBetween,
+	"like":    TokenLike,
+	"not":     TokenNot,
+}
+
+const eof = rune(0)
+
+// Token is a single lexical unit produced by a Lexer. Ts and Te are byte
+// offsets into the source the Lexer was constructed with; Line and Col
+// locate Ts for diagnostics, both 1-based. Value holds the parsed Go value
+// of a TokenInt, TokenFloat or TokenImag token (int64, float64 or
+// complex128 respectively), so callers don't need a second parsing pass.
+type Token struct {
+	Typ    TokenType
+	Ts, Te int
+	Line   uint
+	Col    uint
+	Value  interface{}
+}
+
+// Repr returns the token's source text, given the same src passed to NewLexer.
+func (t Token) Repr(src string) string {
+	return src[t.Ts:t.Te]
+}
+
+// LexError describes a malformed input encountered while scanning. Scanning
+// continues past a LexError where possible, so a Lexer may report several of
+// these over the course of consuming its input.
+type LexError struct {
+	Line uint
+	Col  uint
+	Msg  string
+}
+
+func (e *LexError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Col, e.Msg)
+}
+
+func lower(r rune) rune {
+	return ('a' - 'A') | r
+}
+
+const whitespace = uint64(1<<'\t' | 1<<'\n' | 1<<'\r' | 1<<' ')
+
+func isWhitespace(r rune) bool {
+	return whitespace&(1<<uint(r)) != 0
+}
+
+func isBinRune(r rune) bool {
+	return r == '0' || r == '1'
+}
+
+func isOctalRune(r rune) bool {
+	return r >= '0' && r <= '7'
+}
+
+func isDecimalRune(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+func isHexRune(r rune) bool {
+	if isDecimalRune(r) {
+		return true
+	}
+	r = lower(r)
+	return r >= 'a' && r <= 'f'
+}
+
+// isLetter and isDigit decide what may start, and continue, an identifier:
+// a leading letter or underscore, followed by letters, underscores or digits.
+func isLetter(r rune) bool {
+	return r == '_' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') ||
+		(r >= utf8.RuneSelf && unicode.IsLetter(r))
+}
+
+func isDigit(r rune) bool {
+	return isDecimalRune(r) || (r >= utf8.RuneSelf && unicode.IsDigit(r))
+}
+
+// Lexer tokenizes a constraint expression. Set Errh before the first call to
+// Next if you want diagnostics reported as they're found, in addition to the
+// error value Next returns for the affected token.
+type Lexer struct {
+	src string
+
+	// Errh, if non-nil, is invoked for every malformed construct the Lexer
+	// recovers from, in addition to the error returned from Next.
+	Errh func(line, col uint, msg string)
+
+	lineOffsets []int // byte offset of the start of each line, 0-based
+
+	bc  int // byte count
+	cc  int // char count
+	lcw int // last char width, for one-level prev()
+
+	pending []Token // tokens already scanned but not yet returned by Next
+	nerrors int
+	lastErr *LexError
+}
+
+// NewLexer returns a Lexer positioned at the start of src.
+func NewLexer(src string) *Lexer {
+	l := &Lexer{src: src, lcw: -1, lineOffsets: []int{0}}
+	for i, r := range src {
+		if r == '\n' {
+			l.lineOffsets = append(l.lineOffsets, i+1)
+		}
+	}
+	return l
+}
+
+// ErrorCount reports how many malformed constructs have been recovered from
+// so far.
+func (l *Lexer) ErrorCount() int {
+	return l.nerrors
+}
+
+func (l *Lexer) position(offs int) (line, col uint) {
+	i := sort.Search(len(l.lineOffsets), func(i int) bool { return l.lineOffsets[i] > offs }) - 1
+	if i < 0 {
+		i = 0
+	}
+	return uint(i + 1), uint(offs-l.lineOffsets[i]) + 1
+}
+
+func (l *Lexer) errorf(offs int, format string, args ...interface{}) {
+	l.nerrors++
+	line, col := l.position(offs)
+	msg := fmt.Sprintf(format, args...)
+	l.lastErr = &LexError{Line: line, Col: col, Msg: msg}
+	if l.Errh != nil {
+		l.Errh(line, col, msg)
+	}
+}
+
+func (l *Lexer) tok(typ TokenType, ts, te int) Token {
+	line, col := l.position(ts)
+	return Token{Typ: typ, Ts: ts, Te: te, Line: line, Col: col}
+}
+
+func (l *Lexer) emit(typ TokenType, ts, te int) {
+	l.pending = append(l.pending, l.tok(typ, ts, te))
+}
+
+func (l *Lexer) next() rune {
+	if l.bc >= len(l.src) {
+		if l.bc > len(l.src) {
+			panic("orbis: lexer read past end of input")
+		}
+		l.lcw = 0
+		return eof
+	}
+	r, cw := utf8.DecodeRuneInString(l.src[l.bc:])
+	l.bc += cw
+	l.lcw = cw
+	l.cc++
+	return r
+}
+
+func (l *Lexer) prev() {
+	if l.lcw < 0 {
+		panic("orbis: lexer stepped back too far")
+	}
+	if l.lcw == 0 {
+		// the last next() hit eof; there's nothing to step back over.
+		l.lcw = -1
+		return
+	}
+	l.bc -= l.lcw
+	l.lcw = -1
+	l.cc--
+}
+
+func (l *Lexer) lexEscapeChar(offs int, quote rune) {
+	r := l.next()
+
+	skip := func(n int, pred func(rune) bool) {
+		for n > 0 {
+			r = l.next()
+			if r == eof || !pred(r) {
+				l.errorf(offs, "escape sequence not terminated")
+				return
+			}
+			n--
+		}
+	}
+
+	switch r {
+	case eof:
+		l.errorf(offs, "escape sequence not terminated")
+	case quote, 'a', 'b', 'f', 'n', 'r', 't', 'v', '\\':
+		// ignore
+	case 'x':
+		skip(2, isHexRune)
+	case 'u':
+		skip(4, isHexRune)
+	case 'U':
+		skip(8, isHexRune)
+	default:
+		if !isOctalRune(r) {
+			l.errorf(offs, "unknown escape sequence %q", r)
+			return
+		}
+		skip(2, isOctalRune)
+	}
+}
+
+func (l *Lexer) lexNumber(offs int, r rune) TokenType {
+	var (
+		digit  bool
+		prefix rune
+		float  bool
+	)
+
+	float = r == '.'
+
+	skip := func(pred func(rune) bool) {
+		for {
+			switch {
+			case r == '_':
+				r = l.next()
+				continue
+			case pred(r):
+				digit = true
+				r = l.next()
+				continue
+			}
+			break
+		}
+	}
+
+	if r == '0' {
+		prefix = lower(l.next())
+
+		switch prefix {
+		case 'x':
+			r = l.next()
+			skip(isHexRune)
+		case 'o':
+			r = l.next()
+			skip(isOctalRune)
+		case 'b':
+			r = l.next()
+			skip(isBinRune)
+		case '.':
+			prefix, digit, float = '0', true, true
+		default:
+			prefix, digit = '0', true
+			skip(isOctalRune)
+		}
+	} else {
+		skip(isDecimalRune)
+	}
+
+	if !float {
+		float = r == '.'
+	}
+
+	if float {
+		if prefix == 'o' || prefix == 'b' {
+			l.errorf(offs, "invalid radix point in number literal")
+		}
+
+		// Not lowered: the predicates below lower case-sensitive runes
+		// themselves, and a digit separator here must stay '_' rather
+		// than be mangled into an unrelated control rune.
+		r = l.next()
+
+		switch prefix {
+		case 'x':
+			skip(isHexRune)
+		case '0':
+			skip(isOctalRune)
+		default:
+			skip(isDecimalRune)
+		}
+	}
+
+	if !digit {
+		l.errorf(offs, "number has no digits")
+	}
+
+	e := lower(r)
+
+	if e == 'e' || e == 'p' {
+		if e == 'e' && prefix != eof && prefix != '0' {
+			l.errorf(offs, "%q exponent requires decimal mantissa", r)
+		}
+		if e == 'p' && prefix != 'x' {
+			l.errorf(offs, "%q exponent requires hexadecimal mantissa", r)
+		}
+
+		r = l.next()
+		if r == '+' || r == '-' {
+			r = l.next()
+		}
+
+		float = true
+		digit = false
+
+		skip(isDecimalRune)
+
+		if !digit {
+			l.errorf(offs, "exponent has no digits")
+		}
+	} else if float && prefix == 'x' {
+		l.errorf(offs, "hexadecimal mantissa requires a 'p' exponent")
+	}
+
+	imag := r == 'i'
+	if imag {
+		r = l.next()
+	}
+
+	l.prev()
+
+	if lit := l.src[offs:l.bc]; digit {
+		if i := invalidSeparator(lit); i >= 0 {
+			l.errorf(offs+i, "'_' must separate successive digits")
+		}
+	}
+
+	switch {
+	case imag:
+		return TokenImag
+	case float:
+		return TokenFloat
+	default:
+		return TokenInt
+	}
+}
+
+// invalidSeparator returns the byte offset of a misplaced '_' digit
+// separator in lit (a fully-scanned number literal), or -1 if lit's
+// separators, if any, all sit between two digits of the same literal.
+func invalidSeparator(lit string) int {
+	// x1 tracks whether we're scanning the hex mantissa, where 'a'-'f' also
+	// count as digits; d tracks whether the previous byte was a digit.
+	x1 := rune(' ')
+	d := rune('.')
+	i := 0
+
+	if len(lit) >= 2 && lit[0] == '0' {
+		x1 = lower(rune(lit[1]))
+		if x1 == 'x' || x1 == 'o' || x1 == 'b' {
+			d = '0'
+			i = 2
+		}
+	}
+
+	for ; i < len(lit); i++ {
+		p := d
+		d = rune(lit[i])
+		switch {
+		case d == '_':
+			if p != '0' {
+				return i
+			}
+		case isDecimalRune(d) || (x1 == 'x' && isHexRune(d)):
+			d = '0'
+		default:
+			if p == '_' {
+				return i - 1
+			}
+			d = '.'
+		}
+	}
+	if d == '_' {
+		return len(lit) - 1
+	}
+
+	return -1
+}
+
+// literalValue parses the Go value of a number literal already validated by
+// lexNumber, after stripping digit separators.
+func literalValue(typ TokenType, lit string) interface{} {
+	lit = strings.ReplaceAll(lit, "_", "")
+
+	switch typ {
+	case TokenInt:
+		n, err := strconv.ParseInt(lit, 0, 64)
+		if err != nil {
+			return nil
+		}
+		return n
+	case TokenFloat:
+		f, err := strconv.ParseFloat(lit, 64)
+		if err != nil {
+			return nil
+		}
+		return f
+	case TokenImag:
+		f, err := strconv.ParseFloat(strings.TrimSuffix(lit, "i"), 64)
+		if err != nil {
+			return nil
+		}
+		return complex(0, f)
+	default:
+		return nil
+	}
+}
+
+func (l *Lexer) lexText(offs int, quote rune) {
+	l.emit(TokenStringStart, offs, l.bc)
+
+	start, end := l.bc, -1
+
+	for {
+		switch l.next() {
+		default:
+			continue
+		case '\\':
+			l.lexEscapeChar(offs, quote)
+			continue
+		case quote:
+			end = l.bc - 1
+		case eof, '\n':
+			// unterminated; fall through and recover below
+		}
+		break
+	}
+
+	endTe := end + 1
+	if end == -1 {
+		l.errorf(offs, "string literal not terminated")
+		end, endTe = l.bc, l.bc
+	}
+
+	if start != end {
+		l.emit(TokenText, start, end)
+	}
+	l.emit(TokenStringEnd, end, endTe)
+}
+
+// peekIs reports whether the unconsumed input starts with s, without
+// advancing the lexer.
+func (l *Lexer) peekIs(s string) bool {
+	return l.bc+len(s) <= len(l.src) && l.src[l.bc:l.bc+len(s)] == s
+}
+
+// lexRawString scans a Go-style raw string: backtick-delimited, with no
+// escape processing, terminated only by the closing backtick.
+func (l *Lexer) lexRawString(offs int) {
+	l.emit(TokenStringStart, offs, l.bc)
+
+	start, end := l.bc, -1
+
+	for {
+		switch l.next() {
+		case '`':
+			end = l.bc - 1
+		case eof:
+			// unterminated; fall through and recover below
+		default:
+			continue
+		}
+		break
+	}
+
+	endTe := end + 1
+	if end == -1 {
+		l.errorf(offs, "raw string literal not terminated")
+		end, endTe = l.bc, l.bc
+	}
+
+	if start != end {
+		l.emit(TokenRawString, start, end)
+	}
+	l.emit(TokenStringEnd, end, endTe)
+}
+
+// lexBlockString scans a GraphQL-style block string: delimited by a leading
+// and trailing `"""`, spanning newlines, with no escape processing. Common
+// leading whitespace is stripped later, at token materialization time, by
+// dedentBlockString.
+func (l *Lexer) lexBlockString(offs int) {
+	l.emit(TokenStringStart, offs, l.bc)
+
+	start, end, endTe := l.bc, -1, -1
+
+	for {
+		if l.peekIs(`"""`) {
+			end = l.bc
+			l.next()
+			l.next()
+			l.next()
+			endTe = l.bc
+			break
+		}
+		if l.next() == eof {
+			break
+		}
+	}
+
+	if end == -1 {
+		l.errorf(offs, "block string literal not terminated")
+		end, endTe = l.bc, l.bc
+	}
+
+	if start != end {
+		l.emit(TokenBlockString, start, end)
+	}
+	l.emit(TokenStringEnd, end, endTe)
+}
+
+// dedentBlockString implements the GraphQL block string value algorithm:
+// the minimum indentation among all non-first lines that contain non-
+// whitespace is stripped from every non-first line, and leading/trailing lines
+// that are entirely whitespace are dropped.
+func dedentBlockString(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+
+	commonIndent := -1
+	for i := 1; i < len(lines); i++ {
+		indent := leadingWhitespaceLen(lines[i])
+		if indent == len(lines[i]) {
+			continue // all whitespace; doesn't constrain the common indent
+		}
+		if commonIndent == -1 || indent < commonIndent {
+			commonIndent = indent
+		}
+	}
+
+	if commonIndent > 0 {
+		for i := 1; i < len(lines); i++ {
+			if len(lines[i]) >= commonIndent {
+				lines[i] = lines[i][commonIndent:]
+			} else {
+				lines[i] = ""
+			}
+		}
+	}
+
+	for len(lines) > 0 && isBlankLine(lines[0]) {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && isBlankLine(lines[len(lines)-1]) {
+		lines = lines[:len(lines)-1]
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func leadingWhitespaceLen(s string) int {
+	i := 0
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+		i++
+	}
+	return i
+}
+
+func isBlankLine(s string) bool {
+	return leadingWhitespaceLen(s) == len(s)
+}
+
+// Next scans and returns the next token in the input. It returns io.EOF once
+// the input is exhausted. On malformed input it returns a *LexError alongside
+// the best token it could recover, rather than aborting the scan.
+func (l *Lexer) Next() (Token, error) {
+	if len(l.pending) > 0 {
+		t := l.pending[0]
+		l.pending = l.pending[1:]
+		return t, nil
+	}
+
+	before := l.nerrors
+
+	r := l.next()
+	for isWhitespace(r) {
+		r = l.next()
+	}
+	if r == eof {
+		return Token{}, io.EOF
+	}
+
+	if isDecimalRune(r) || r == '.' {
+		s := l.bc - 1
+
+		typ := l.lexNumber(s, r)
+		lit := l.src[s:l.bc]
+		l.emit(typ, s, l.bc)
+		l.pending[len(l.pending)-1].Value = literalValue(typ, lit)
+	} else if isLetter(r) {
+		s := l.bc - 1
+
+		for r = l.next(); isLetter(r) || isDigit(r); r = l.next() {
+		}
+		l.prev()
+
+		typ, ok := keywords[l.src[s:l.bc]]
+		if !ok {
+			typ = TokenIdent
+		}
+		l.emit(typ, s, l.bc)
+	} else {
+		switch r {
+		case '\'':
+			l.lexText(l.bc-1, r)
+		case '"':
+			if l.peekIs(`""`) {
+				l.next()
+				l.next()
+				l.lexBlockString(l.bc - 3)
+			} else {
+				l.lexText(l.bc-1, r)
+			}
+		case '`':
+			l.lexRawString(l.bc - 1)
+		case '>':
+			if r = l.next(); r == '=' {
+				l.emit(TokenGTE, l.bc-2, l.bc)
+			} else {
+				l.prev()
+				l.emit(TokenGT, l.bc-1, l.bc)
+			}
+		case '<':
+			if r = l.next(); r == '=' {
+				l.emit(TokenLTE, l.bc-2, l.bc)
+			} else {
+				l.prev()
+				l.emit(TokenLT, l.bc-1, l.bc)
+			}
+		case '=':
+			l.emit(TokenEQ, l.bc-1, l.bc)
+		case '!':
+			if r = l.next(); r == '=' {
+				l.emit(TokenNEQ, l.bc-2, l.bc)
+			} else {
+				if r != eof {
+					l.prev()
+				}
+				l.errorf(l.bc-1, "expected '=' after '!'")
+				return l.nextAfterError()
+			}
+		case '(':
+			l.emit(TokenBracketStart, l.bc-1, l.bc)
+		case ')':
+			l.emit(TokenBracketEnd, l.bc-1, l.bc)
+		case '[':
+			l.emit(TokenListStart, l.bc-1, l.bc)
+		case ']':
+			l.emit(TokenListEnd, l.bc-1, l.bc)
+		case ',':
+			l.emit(TokenComma, l.bc-1, l.bc)
+		case '&':
+			l.emit(TokenAND, l.bc-1, l.bc)
+		case '|':
+			l.emit(TokenOR, l.bc-1, l.bc)
+		default:
+			l.errorf(l.bc-1, "unexpected character %q", r)
+			return l.nextAfterError()
+		}
+	}
+
+	t := l.pending[0]
+	l.pending = l.pending[1:]
+
+	if l.nerrors > before {
+		return t, l.lastErr
+	}
+	return t, nil
+}
+
+// nextAfterError scans past a character that produced no token, returning
+// the following token together with the error just recorded. At the end of
+// input it returns io.EOF instead.
+func (l *Lexer) nextAfterError() (Token, error) {
+	t, err := l.Next()
+	if err == nil {
+		err = l.lastErr
+	}
+	return t, err
+}
@@ -0,0 +1,90 @@
+package orbis
+
+import (
+	"io"
+	"testing"
+)
+
+func scanNumber(t *testing.T, src string) (Token, []string) {
+	t.Helper()
+
+	var errs []string
+	l := NewLexer(src)
+	l.Errh = func(line, col uint, msg string) {
+		errs = append(errs, msg)
+	}
+
+	tok, err := l.Next()
+	if err != nil && err != io.EOF {
+		errs = append(errs, err.Error())
+	}
+	return tok, errs
+}
+
+func TestLexerNumberValue(t *testing.T) {
+	tests := []struct {
+		src  string
+		typ  TokenType
+		want interface{}
+	}{
+		{"123", TokenInt, int64(123)},
+		{"1_000_000", TokenInt, int64(1000000)},
+		{"0x1p4", TokenFloat, float64(16)},
+		{"0.5", TokenFloat, float64(0.5)},
+		{"0.25", TokenFloat, float64(0.25)},
+		{"0.123e4", TokenFloat, float64(1230)},
+		{"3i", TokenImag, complex(0, 3)},
+		{"1.5i", TokenImag, complex(0, 1.5)},
+	}
+
+	for _, tt := range tests {
+		tok, errs := scanNumber(t, tt.src)
+		if len(errs) != 0 {
+			t.Errorf("scanning %q reported errors: %v", tt.src, errs)
+			continue
+		}
+		if tok.Typ != tt.typ {
+			t.Errorf("scanning %q: got token type %v, want %v", tt.src, tok.Typ, tt.typ)
+		}
+		if tok.Value != tt.want {
+			t.Errorf("scanning %q: got value %#v, want %#v", tt.src, tok.Value, tt.want)
+		}
+	}
+}
+
+func TestLexerKeywordsAndListTokens(t *testing.T) {
+	l := NewLexer(`in between like not [ , ]`)
+	l.Errh = func(line, col uint, msg string) {
+		t.Errorf("unexpected lexer error at %d:%d: %s", line, col, msg)
+	}
+
+	want := []TokenType{
+		TokenIn, TokenBetween, TokenLike, TokenNot,
+		TokenListStart, TokenComma, TokenListEnd,
+	}
+	for _, typ := range want {
+		tok, err := l.Next()
+		if err != nil {
+			t.Fatalf("Next() returned error: %v", err)
+		}
+		if tok.Typ != typ {
+			t.Errorf("got token type %v, want %v", tok.Typ, typ)
+		}
+	}
+}
+
+func TestLexerInvalidSeparators(t *testing.T) {
+	tests := []string{
+		"123_",
+		"1__23",
+		"1_.5",
+		"1._5",
+	}
+
+	for _, src := range tests {
+		_, errs := scanNumber(t, src)
+		if len(errs) == 0 {
+			t.Errorf("scanning %q: expected a separator error, got none", src)
+		}
+	}
+}
@@ -0,0 +1,366 @@
+package orbis
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ParseError describes a malformed constraint expression. Unlike LexError,
+// a ParseError is fatal: Parse stops at the first one, since the token
+// stream no longer forms a valid expression to recover into.
+type ParseError struct {
+	Line uint
+	Col  uint
+	Msg  string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Col, e.Msg)
+}
+
+// Parser turns the token stream from a Lexer into an Expr. Use Parse for
+// one-shot parsing, or NewParser and ParseExpr to drive parsing directly.
+// A Parser always creates its own Lexer; it cannot be built over an existing
+// one.
+type Parser struct {
+	src string
+	lex *Lexer
+
+	tok   Token
+	atEOF bool
+}
+
+// NewParser returns a Parser reading src. Lexer errors it recovers from are
+// discarded; use Parse if you want parsing to fail on them.
+func NewParser(src string) *Parser {
+	p := &Parser{src: src, lex: NewLexer(src)}
+	p.advance()
+	return p
+}
+
+// Parse parses src as a single constraint expression and returns its AST.
+func Parse(src string) (Expr, error) {
+	p := &Parser{src: src, lex: NewLexer(src)}
+
+	var lexErr error
+	p.lex.Errh = func(line, col uint, msg string) {
+		if lexErr == nil {
+			lexErr = &LexError{Line: line, Col: col, Msg: msg}
+		}
+	}
+	p.advance()
+
+	expr, err := p.ParseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if lexErr != nil {
+		return nil, lexErr
+	}
+	if !p.atEOF {
+		return nil, p.errorf("unexpected %s", p.tok.Repr(p.src))
+	}
+	return expr, nil
+}
+
+func (p *Parser) advance() {
+	tok, err := p.lex.Next()
+	if err == io.EOF {
+		p.atEOF = true
+		p.tok = Token{}
+		return
+	}
+	p.tok = tok
+}
+
+func (p *Parser) errorf(format string, args ...interface{}) error {
+	return &ParseError{Line: p.tok.Line, Col: p.tok.Col, Msg: fmt.Sprintf(format, args...)}
+}
+
+// ParseExpr parses a full boolean expression: `|` binds loosest, `&` binds
+// tighter, and parens override both.
+func (p *Parser) ParseExpr() (Expr, error) {
+	return p.parseOr()
+}
+
+func (p *Parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for !p.atEOF && p.tok.Typ == TokenOR {
+		p.advance()
+
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &OrExpr{X: left, Y: right}
+	}
+
+	return left, nil
+}
+
+func (p *Parser) parseAnd() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for !p.atEOF && p.tok.Typ == TokenAND {
+		p.advance()
+
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &AndExpr{X: left, Y: right}
+	}
+
+	return left, nil
+}
+
+// parseUnary parses an optional `not` prefix, which binds tighter than `&`.
+func (p *Parser) parseUnary() (Expr, error) {
+	if !p.atEOF && p.tok.Typ == TokenNot {
+		p.advance()
+
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &NotExpr{X: x}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *Parser) parsePrimary() (Expr, error) {
+	if p.atEOF {
+		return nil, p.errorf("unexpected end of expression")
+	}
+
+	if p.tok.Typ == TokenBracketStart {
+		p.advance()
+
+		x, err := p.ParseExpr()
+		if err != nil {
+			return nil, err
+		}
+
+		if p.atEOF || p.tok.Typ != TokenBracketEnd {
+			return nil, p.errorf("expected ')'")
+		}
+		p.advance()
+
+		return &ParenExpr{X: x}, nil
+	}
+
+	return p.parseCmp()
+}
+
+var cmpOps = map[TokenType]CmpOp{
+	TokenGT:  OpGT,
+	TokenGTE: OpGTE,
+	TokenLT:  OpLT,
+	TokenLTE: OpLTE,
+	TokenEQ:  OpEQ,
+	TokenNEQ: OpNEQ,
+}
+
+func (p *Parser) parseCmp() (Expr, error) {
+	if p.atEOF || p.tok.Typ != TokenIdent {
+		return nil, p.errorf("expected a field name")
+	}
+	field := p.tok.Repr(p.src)
+	p.advance()
+
+	if p.atEOF {
+		return nil, p.errorf("expected a comparison operator")
+	}
+
+	switch p.tok.Typ {
+	case TokenIn:
+		p.advance()
+		return p.parseIn(field)
+	case TokenBetween:
+		p.advance()
+		return p.parseBetween(field)
+	case TokenLike:
+		p.advance()
+		return p.parseLike(field)
+	}
+
+	op, ok := cmpOps[p.tok.Typ]
+	if !ok {
+		return nil, p.errorf("expected a comparison operator")
+	}
+	p.advance()
+
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+
+	return &CmpExpr{Op: op, Field: field, Value: value}, nil
+}
+
+// parseIn parses the `[v1, v2, ...]` list following `field in`.
+func (p *Parser) parseIn(field string) (Expr, error) {
+	if p.atEOF || p.tok.Typ != TokenListStart {
+		return nil, p.errorf("expected '['")
+	}
+	p.advance()
+
+	var items []Expr
+	if p.atEOF || p.tok.Typ != TokenListEnd {
+		for {
+			item, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+
+			if p.atEOF || p.tok.Typ != TokenComma {
+				break
+			}
+			p.advance()
+		}
+	}
+
+	if p.atEOF || p.tok.Typ != TokenListEnd {
+		return nil, p.errorf("expected ']'")
+	}
+	p.advance()
+
+	return &InExpr{Field: field, Items: items}, nil
+}
+
+// parseBetween parses the `Low and High` bounds following `field between`.
+func (p *Parser) parseBetween(field string) (Expr, error) {
+	low, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.atEOF || p.tok.Typ != TokenIdent || p.tok.Repr(p.src) != "and" {
+		return nil, p.errorf("expected 'and'")
+	}
+	p.advance()
+
+	high, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+
+	return &BetweenExpr{Field: field, Low: low, High: high}, nil
+}
+
+// parseLike parses the string pattern following `field like`.
+func (p *Parser) parseLike(field string) (Expr, error) {
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := value.(*StringLit); !ok {
+		return nil, p.errorf("like pattern must be a string literal")
+	}
+
+	return &CmpExpr{Op: OpLike, Field: field, Value: value}, nil
+}
+
+func (p *Parser) parseValue() (Expr, error) {
+	if p.atEOF {
+		return nil, p.errorf("expected a value")
+	}
+
+	switch p.tok.Typ {
+	case TokenInt:
+		n, ok := p.tok.Value.(int64)
+		if !ok {
+			return nil, p.errorf("invalid integer literal %q", p.tok.Repr(p.src))
+		}
+		p.advance()
+		return &IntLit{Value: n}, nil
+	case TokenFloat:
+		f, ok := p.tok.Value.(float64)
+		if !ok {
+			return nil, p.errorf("invalid float literal %q", p.tok.Repr(p.src))
+		}
+		p.advance()
+		return &FloatLit{Value: f}, nil
+	case TokenStringStart:
+		return p.parseString()
+	default:
+		return nil, p.errorf("expected a value")
+	}
+}
+
+func (p *Parser) parseString() (Expr, error) {
+	quote := p.src[p.tok.Ts]
+	kind := TokenText
+	p.advance()
+
+	var raw string
+	if !p.atEOF && (p.tok.Typ == TokenText || p.tok.Typ == TokenRawString || p.tok.Typ == TokenBlockString) {
+		kind = p.tok.Typ
+		raw = p.tok.Repr(p.src)
+		p.advance()
+	}
+
+	if p.atEOF || p.tok.Typ != TokenStringEnd {
+		return nil, p.errorf("unterminated string literal")
+	}
+	p.advance()
+
+	var value string
+	var err error
+	switch kind {
+	case TokenRawString:
+		value = raw
+	case TokenBlockString:
+		value = dedentBlockString(raw)
+	default:
+		value, err = decodeString(raw, rune(quote))
+	}
+	if err != nil {
+		return nil, p.errorf("invalid string literal: %v", err)
+	}
+
+	return &StringLit{Value: value}, nil
+}
+
+// decodeString decodes the Go-style escape sequences in raw, the interior
+// text of a '...' or "..." literal as produced by the Lexer.
+func decodeString(raw string, quote rune) (string, error) {
+	var b strings.Builder
+
+	for len(raw) > 0 {
+		if raw[0] != '\\' {
+			i := strings.IndexByte(raw, '\\')
+			if i < 0 {
+				b.WriteString(raw)
+				break
+			}
+			b.WriteString(raw[:i])
+			raw = raw[i:]
+			continue
+		}
+
+		r, multibyte, tail, err := strconv.UnquoteChar(raw, byte(quote))
+		if err != nil {
+			return "", err
+		}
+		if multibyte {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte(byte(r))
+		}
+		raw = tail
+	}
+
+	return b.String(), nil
+}
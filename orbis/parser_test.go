@@ -0,0 +1,144 @@
+package orbis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		src  string
+		want Expr
+	}{
+		{
+			src: `age >= 18`,
+			want: &CmpExpr{Op: OpGTE, Field: "age", Value: &IntLit{Value: 18}},
+		},
+		{
+			src: `age >= 18 & name = "bob"`,
+			want: &AndExpr{
+				X: &CmpExpr{Op: OpGTE, Field: "age", Value: &IntLit{Value: 18}},
+				Y: &CmpExpr{Op: OpEQ, Field: "name", Value: &StringLit{Value: "bob"}},
+			},
+		},
+		{
+			src: `a = 1 | b = 2 & c = 3`,
+			want: &OrExpr{
+				X: &CmpExpr{Op: OpEQ, Field: "a", Value: &IntLit{Value: 1}},
+				Y: &AndExpr{
+					X: &CmpExpr{Op: OpEQ, Field: "b", Value: &IntLit{Value: 2}},
+					Y: &CmpExpr{Op: OpEQ, Field: "c", Value: &IntLit{Value: 3}},
+				},
+			},
+		},
+		{
+			src: `(a = 1 | b = 2) & c != 3.5`,
+			want: &AndExpr{
+				X: &ParenExpr{X: &OrExpr{
+					X: &CmpExpr{Op: OpEQ, Field: "a", Value: &IntLit{Value: 1}},
+					Y: &CmpExpr{Op: OpEQ, Field: "b", Value: &IntLit{Value: 2}},
+				}},
+				Y: &CmpExpr{Op: OpNEQ, Field: "c", Value: &FloatLit{Value: 3.5}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%q) returned error: %v", tt.src, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %#v, want %#v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestParseRawAndBlockStrings(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"path = `C:\\no\\escapes`", `C:\no\escapes`},
+		{"path = ``", ""},
+		{"doc = \"\"\"\n    hello\n    world\n    \"\"\"", "hello\nworld"},
+	}
+
+	for _, tt := range tests {
+		expr, err := Parse(tt.src)
+		if err != nil {
+			t.Fatalf("Parse(%q) returned error: %v", tt.src, err)
+		}
+		cmp, ok := expr.(*CmpExpr)
+		if !ok {
+			t.Fatalf("Parse(%q) = %#v, want *CmpExpr", tt.src, expr)
+		}
+		lit, ok := cmp.Value.(*StringLit)
+		if !ok {
+			t.Fatalf("Parse(%q) value = %#v, want *StringLit", tt.src, cmp.Value)
+		}
+		if lit.Value != tt.want {
+			t.Errorf("Parse(%q) = %q, want %q", tt.src, lit.Value, tt.want)
+		}
+	}
+}
+
+func TestParseInBetweenLikeNot(t *testing.T) {
+	tests := []struct {
+		src  string
+		want Expr
+	}{
+		{
+			src: `status in [1, 2, 3]`,
+			want: &InExpr{Field: "status", Items: []Expr{
+				&IntLit{Value: 1}, &IntLit{Value: 2}, &IntLit{Value: 3},
+			}},
+		},
+		{
+			src: `status in []`,
+			want: &InExpr{Field: "status"},
+		},
+		{
+			src: `age between 10 and 20`,
+			want: &BetweenExpr{Field: "age", Low: &IntLit{Value: 10}, High: &IntLit{Value: 20}},
+		},
+		{
+			src:  `name like "foo%"`,
+			want: &CmpExpr{Op: OpLike, Field: "name", Value: &StringLit{Value: "foo%"}},
+		},
+		{
+			src:  `not age >= 18`,
+			want: &NotExpr{X: &CmpExpr{Op: OpGTE, Field: "age", Value: &IntLit{Value: 18}}},
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%q) returned error: %v", tt.src, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %#v, want %#v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		`age >=`,
+		`age >= 18 &`,
+		`(age >= 18`,
+		`"unterminated`,
+		"a = `unterminated",
+		"a = \"\"\"unterminated",
+		`18 >= age`,
+	}
+
+	for _, src := range tests {
+		if _, err := Parse(src); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", src)
+		}
+	}
+}